@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Point is a single metric sample to be delivered to the configured output
+// backend, independent of any particular backend's wire format.
+type Point struct {
+	Name      string
+	Tags      map[string]string
+	Fields    map[string]interface{}
+	Timestamp time.Time
+}
+
+// Annotation is the synthetic "sensu_event" series emitted alongside metric
+// points when an event needs one, see eventNeedsAnnotation.
+type Annotation struct {
+	Name      string
+	Tags      map[string]string
+	Fields    map[string]interface{}
+	Timestamp time.Time
+}
+
+// Exporter delivers a batch of points and annotations to a metrics backend.
+// entity is the sensu entity name the batch was produced from; backends that
+// need to identify the source (e.g. OTLP's resource attributes) use it
+// directly rather than trying to recover it from a possibly
+// --tags-template-customized tag map.
+type Exporter interface {
+	Export(ctx context.Context, entity string, points []Point, annotations []Annotation) error
+}
+
+// newExporter builds the Exporter selected by config.Output.
+func newExporter() (Exporter, error) {
+	switch config.Output {
+	case "", "influxdb":
+		return &influxdbExporter{}, nil
+	case "prometheus-rw":
+		return &prometheusRemoteWriteExporter{}, nil
+	case "otlp-http":
+		return &otlpHTTPExporter{}, nil
+	default:
+		return nil, fmt.Errorf("--output must be one of: influxdb, prometheus-rw, otlp-http")
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// influxdbExporter writes points and annotations to InfluxDB as line
+// protocol, via the batching/retrying/spooling Writer. This is the original,
+// default behavior of the handler and is selected with --output=influxdb.
+type influxdbExporter struct{}
+
+func (e *influxdbExporter) Export(ctx context.Context, entity string, points []Point, annotations []Annotation) error {
+	var lines []string
+	for _, p := range points {
+		lines = append(lines, formatLine(p.Name, p.Tags, p.Fields, p.Timestamp))
+	}
+	for _, a := range annotations {
+		lines = append(lines, formatLine(a.Name, a.Tags, a.Fields, a.Timestamp))
+	}
+
+	if len(lines) == 0 {
+		return nil
+	}
+
+	w := NewWriter(httpPost(writeURL(), setAuthHeaders))
+	for _, line := range lines {
+		if err := w.WriteLine(ctx, line); err != nil {
+			return err
+		}
+	}
+
+	return w.Flush(ctx)
+}
+
+// setAuthHeaders attaches the authentication appropriate to config.APIVersion
+// to an outgoing InfluxDB write request.
+func setAuthHeaders(req *http.Request) {
+	if config.APIVersion == "2" {
+		req.Header.Set("Authorization", "Token "+config.Token)
+	} else if len(config.Username) > 0 || len(config.Password) > 0 {
+		req.SetBasicAuth(config.Username, config.Password)
+	}
+}
+
+// writeURL builds the write endpoint for the configured API version.
+func writeURL() string {
+	if config.APIVersion == "2" {
+		return fmt.Sprintf("%s/api/v2/write?org=%s&bucket=%s&precision=%s",
+			config.Addr, config.Org, config.Bucket, precisionMap[config.Precision])
+	}
+	return fmt.Sprintf("%s/write?db=%s&precision=%s", config.Addr, config.DbName, precisionMap[config.Precision])
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+
+	collectorpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// otlpHTTPExporter writes points and annotations to an OTLP/HTTP collector
+// as an ExportMetricsServiceRequest protobuf, selected with
+// --output=otlp-http.
+type otlpHTTPExporter struct{}
+
+func (e *otlpHTTPExporter) Export(ctx context.Context, entity string, points []Point, annotations []Annotation) error {
+	var metrics []*metricspb.Metric
+	for _, p := range points {
+		metrics = append(metrics, metricsForFields(p.Name, p.Tags, p.Fields, p.Timestamp.UnixNano())...)
+	}
+	for _, a := range annotations {
+		metrics = append(metrics, metricsForFields(a.Name, a.Tags, a.Fields, a.Timestamp.UnixNano())...)
+	}
+
+	if len(metrics) == 0 {
+		return nil
+	}
+
+	resource := &resourcepb.Resource{
+		Attributes: []*commonpb.KeyValue{
+			{Key: "service.name", Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: entity}}},
+		},
+	}
+
+	request := &collectorpb.ExportMetricsServiceRequest{
+		ResourceMetrics: []*metricspb.ResourceMetrics{
+			{
+				Resource:     resource,
+				ScopeMetrics: []*metricspb.ScopeMetrics{{Metrics: metrics}},
+			},
+		},
+	}
+
+	body, err := proto.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, config.OTLPEndpoint+"/v1/metrics", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response from otlp/http collector: %s", resp.Status)
+	}
+
+	return nil
+}
+
+// metricsForFields builds one gauge Metric with a single data point per
+// numeric field, using name as the metric name when the field key is
+// "value", or "name_fieldKey" otherwise, matching the measurement/field
+// convention used for line protocol.
+func metricsForFields(name string, tags map[string]string, fields map[string]interface{}, timestampNs int64) []*metricspb.Metric {
+	var metrics []*metricspb.Metric
+
+	fieldKeys := make([]string, 0, len(fields))
+	for k := range fields {
+		fieldKeys = append(fieldKeys, k)
+	}
+	sort.Strings(fieldKeys)
+
+	for _, key := range fieldKeys {
+		value, ok := toFloat64(fields[key])
+		if !ok {
+			continue
+		}
+
+		metricName := name
+		if key != "value" {
+			metricName = name + "_" + key
+		}
+
+		tagKeys := make([]string, 0, len(tags))
+		for k := range tags {
+			tagKeys = append(tagKeys, k)
+		}
+		sort.Strings(tagKeys)
+		attributes := make([]*commonpb.KeyValue, 0, len(tagKeys))
+		for _, k := range tagKeys {
+			attributes = append(attributes, &commonpb.KeyValue{
+				Key:   k,
+				Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: tags[k]}},
+			})
+		}
+
+		metrics = append(metrics, &metricspb.Metric{
+			Name: sanitizeMetricName(metricName),
+			Data: &metricspb.Metric_Gauge{
+				Gauge: &metricspb.Gauge{
+					DataPoints: []*metricspb.NumberDataPoint{
+						{
+							Attributes:   attributes,
+							TimeUnixNano: uint64(timestampNs),
+							Value:        &metricspb.NumberDataPoint_AsDouble{AsDouble: value},
+						},
+					},
+				},
+			},
+		})
+	}
+
+	return metrics
+}
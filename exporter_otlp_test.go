@@ -0,0 +1,95 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	corev2 "github.com/sensu/sensu-go/api/core/v2"
+
+	collectorpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+)
+
+func TestSendMetricsOTLPHTTP(t *testing.T) {
+	assert := assert.New(t)
+	event := corev2.FixtureEvent("entity1", "check1")
+	event.Check = nil
+	event.Metrics = corev2.FixtureMetrics()
+
+	var gotPath string
+	var request collectorpb.ExportMetricsServiceRequest
+	apiStub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		body, _ := ioutil.ReadAll(r.Body)
+		require.NoError(t, proto.Unmarshal(body, &request))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer apiStub.Close()
+
+	config.Output = "otlp-http"
+	config.OTLPEndpoint = apiStub.URL
+	defer func() { config.Output = ""; config.OTLPEndpoint = "" }()
+
+	require.NoError(t, sendMetrics(event))
+
+	assert.Equal("/v1/metrics", gotPath)
+
+	require.Len(t, request.ResourceMetrics, 1)
+	rm := request.ResourceMetrics[0]
+
+	var serviceName string
+	for _, attr := range rm.Resource.Attributes {
+		if attr.Key == "service.name" {
+			serviceName = attr.Value.GetStringValue()
+		}
+	}
+	assert.Equal("entity1", serviceName)
+
+	require.Len(t, rm.ScopeMetrics, 1)
+	require.Len(t, rm.ScopeMetrics[0].Metrics, 1)
+	metric := rm.ScopeMetrics[0].Metrics[0]
+	assert.Equal("answer", metric.Name)
+
+	gauge := metric.GetGauge()
+	require.NotNil(t, gauge)
+	require.Len(t, gauge.DataPoints, 1)
+	assert.Equal(float64(42), gauge.DataPoints[0].GetAsDouble())
+}
+
+func TestSendMetricsOTLPHTTPWithTagsTemplate(t *testing.T) {
+	assert := assert.New(t)
+	event := corev2.FixtureEvent("entity1", "check1")
+	event.Check = nil
+	event.Metrics = corev2.FixtureMetrics()
+
+	var request collectorpb.ExportMetricsServiceRequest
+	apiStub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		require.NoError(t, proto.Unmarshal(body, &request))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer apiStub.Close()
+
+	config.Output = "otlp-http"
+	config.OTLPEndpoint = apiStub.URL
+	config.TagsTemplate = `region=eu` // deliberately drops sensu_entity_name
+	defer func() { config.Output = ""; config.OTLPEndpoint = ""; config.TagsTemplate = "" }()
+
+	require.NoError(t, sendMetrics(event))
+
+	require.Len(t, request.ResourceMetrics, 1)
+	rm := request.ResourceMetrics[0]
+
+	var serviceName string
+	for _, attr := range rm.Resource.Attributes {
+		if attr.Key == "service.name" {
+			serviceName = attr.Value.GetStringValue()
+		}
+	}
+	assert.Equal("entity1", serviceName, "service.name must come from the event entity, not a tag that --tags-template can drop")
+}
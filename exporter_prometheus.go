@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+
+	"github.com/golang/snappy"
+)
+
+// metricNameSanitizer matches the characters prometheus metric and label
+// names may NOT contain.
+var metricNameSanitizer = regexp.MustCompile(`[^a-zA-Z0-9_:]`)
+
+// sanitizeMetricName converts name into a valid prometheus metric/label
+// name: invalid characters become underscores, and a leading digit is
+// prefixed with an underscore since names must match [a-zA-Z_:][a-zA-Z0-9_:]*.
+func sanitizeMetricName(name string) string {
+	sanitized := metricNameSanitizer.ReplaceAllString(name, "_")
+	if len(sanitized) == 0 {
+		return "_"
+	}
+	if sanitized[0] >= '0' && sanitized[0] <= '9' {
+		return "_" + sanitized
+	}
+	return sanitized
+}
+
+// prometheusRemoteWriteExporter writes points and annotations to a
+// prometheus remote-write endpoint as a snappy-compressed
+// WriteRequest, selected with --output=prometheus-rw.
+type prometheusRemoteWriteExporter struct{}
+
+func (e *prometheusRemoteWriteExporter) Export(ctx context.Context, entity string, points []Point, annotations []Annotation) error {
+	var series []TimeSeries
+	for _, p := range points {
+		series = append(series, seriesForFields(p.Name, p.Tags, p.Fields, p.Timestamp.UnixMilli())...)
+	}
+	for _, a := range annotations {
+		series = append(series, seriesForFields(a.Name, a.Tags, a.Fields, a.Timestamp.UnixMilli())...)
+	}
+
+	if len(series) == 0 {
+		return nil
+	}
+
+	body, err := (&WriteRequest{Timeseries: series}).Marshal()
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, config.RemoteWriteURL, bytes.NewReader(snappy.Encode(nil, body)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response from prometheus remote-write endpoint: %s", resp.Status)
+	}
+
+	return nil
+}
+
+// seriesForFields builds one TimeSeries per numeric field of a point,
+// using name as the metric name when the field key is "value", or
+// "name_fieldKey" otherwise, matching the measurement/field convention used
+// for line protocol.
+func seriesForFields(name string, tags map[string]string, fields map[string]interface{}, timestampMs int64) []TimeSeries {
+	var series []TimeSeries
+
+	fieldKeys := make([]string, 0, len(fields))
+	for k := range fields {
+		fieldKeys = append(fieldKeys, k)
+	}
+	sort.Strings(fieldKeys)
+
+	for _, key := range fieldKeys {
+		value, ok := toFloat64(fields[key])
+		if !ok {
+			continue
+		}
+
+		metricName := name
+		if key != "value" {
+			metricName = name + "_" + key
+		}
+
+		labels := []Label{{Name: "__name__", Value: sanitizeMetricName(metricName)}}
+		tagKeys := make([]string, 0, len(tags))
+		for k := range tags {
+			tagKeys = append(tagKeys, k)
+		}
+		sort.Strings(tagKeys)
+		for _, k := range tagKeys {
+			labels = append(labels, Label{Name: sanitizeMetricName(k), Value: tags[k]})
+		}
+
+		series = append(series, TimeSeries{
+			Labels:  labels,
+			Samples: []Sample{{Value: value, Timestamp: timestampMs}},
+		})
+	}
+
+	return series
+}
+
+// toFloat64 converts a line-protocol field value to a prometheus sample
+// value. Prometheus samples are always float64; non-numeric fields (e.g. the
+// quoted strings used in the sensu_event annotation) have no representation
+// and are skipped.
+func toFloat64(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	default:
+		return 0, false
+	}
+}
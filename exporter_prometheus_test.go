@@ -0,0 +1,65 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/snappy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	corev2 "github.com/sensu/sensu-go/api/core/v2"
+)
+
+func TestSendMetricsPrometheusRemoteWrite(t *testing.T) {
+	assert := assert.New(t)
+	event := corev2.FixtureEvent("entity1", "check1")
+	event.Check = nil
+	event.Metrics = corev2.FixtureMetrics()
+
+	var gotEncoding, gotVersion string
+	var writeRequest WriteRequest
+	apiStub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotEncoding = r.Header.Get("Content-Encoding")
+		gotVersion = r.Header.Get("X-Prometheus-Remote-Write-Version")
+
+		compressed, _ := ioutil.ReadAll(r.Body)
+		body, err := snappy.Decode(nil, compressed)
+		require.NoError(t, err)
+		require.NoError(t, writeRequest.Unmarshal(body))
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer apiStub.Close()
+
+	config.Output = "prometheus-rw"
+	config.RemoteWriteURL = apiStub.URL
+	defer func() { config.Output = ""; config.RemoteWriteURL = "" }()
+
+	require.NoError(t, sendMetrics(event))
+
+	assert.Equal("snappy", gotEncoding)
+	assert.Equal("0.1.0", gotVersion)
+
+	require.Len(t, writeRequest.Timeseries, 1)
+	series := writeRequest.Timeseries[0]
+	require.Len(t, series.Samples, 1)
+	assert.Equal(float64(42), series.Samples[0].Value)
+
+	labels := make(map[string]string)
+	for _, l := range series.Labels {
+		labels[l.Name] = l.Value
+	}
+	assert.Equal("answer", labels["__name__"])
+	assert.Equal("bar", labels["foo"])
+	assert.Equal("entity1", labels["sensu_entity_name"])
+}
+
+func TestSanitizeMetricName(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("answer_count", sanitizeMetricName("answer.count"))
+	assert.Equal("_9lives", sanitizeMetricName("9lives"))
+	assert.Equal("already_valid_name", sanitizeMetricName("already_valid_name"))
+}
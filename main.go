@@ -0,0 +1,425 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	corev2 "github.com/sensu/sensu-go/api/core/v2"
+)
+
+// Config holds the runtime configuration for the handler.
+type Config struct {
+	Addr              string
+	APIVersion        string
+	Token             string
+	Org               string
+	Bucket            string
+	Username          string
+	Password          string
+	DbName            string
+	Precision         string
+	CheckStatusMetric bool
+	StripHost         bool
+	Legacy            bool
+	BatchBytes        int
+	BatchFlush        time.Duration
+	Compress          bool
+	SpoolDir          string
+	SpoolMaxBytes     int64
+	Output            string
+	RemoteWriteURL    string
+	OTLPEndpoint      string
+	NameTemplate      string
+	TagsTemplate      string
+}
+
+var config Config
+
+var precisionMap = map[string]string{
+	"ns": "ns",
+	"us": "u",
+	"ms": "ms",
+	"s":  "s",
+}
+
+func init() {
+	for _, name := range []string{"addr", "a"} {
+		flag.StringVar(&config.Addr, name, "http://localhost:8086", "the url of the influxdb server, should be of the form 'http://host:port'")
+	}
+	flag.StringVar(&config.APIVersion, "api-version", "1", "the influxdb api version to write with, '1' or '2'")
+	for _, name := range []string{"token", "t"} {
+		flag.StringVar(&config.Token, name, "", "the influxdb v2 authentication token")
+	}
+	for _, name := range []string{"org", "o"} {
+		flag.StringVar(&config.Org, name, "", "the influxdb v2 org to write to")
+	}
+	for _, name := range []string{"bucket", "b"} {
+		flag.StringVar(&config.Bucket, name, "", "the influxdb v2 bucket to write to")
+	}
+	for _, name := range []string{"username", "u"} {
+		flag.StringVar(&config.Username, name, "", "the username for the given db")
+	}
+	for _, name := range []string{"password", "p"} {
+		flag.StringVar(&config.Password, name, "", "the password for the given db")
+	}
+	for _, name := range []string{"db-name", "d"} {
+		flag.StringVar(&config.DbName, name, "", "influxdb v1 database to send metrics to")
+	}
+	flag.StringVar(&config.Precision, "precision", "s", "the precision of the metric timestamps, one of 'ns', 'us', 'ms', 's'")
+	for _, name := range []string{"check-status-metric", "c"} {
+		flag.BoolVar(&config.CheckStatusMetric, name, false, "if true, the check status result will be captured as a metric")
+	}
+	flag.BoolVar(&config.StripHost, "strip-host", false, "if true, we strip the host from the metric")
+	for _, name := range []string{"legacy", "l"} {
+		flag.BoolVar(&config.Legacy, name, false, "if true, parse the metric w/ legacy format")
+	}
+	flag.IntVar(&config.BatchBytes, "batch-bytes", defaultBatchBytes, "max size in bytes of a batch of points before it's flushed to influxdb")
+	flag.DurationVar(&config.BatchFlush, "batch-flush", time.Second, "max time a batch is held open before it's flushed to influxdb")
+	flag.BoolVar(&config.Compress, "compress", false, "if true, gzip-compress batches before sending them to influxdb")
+	flag.StringVar(&config.SpoolDir, "spool-dir", "", "directory to spool batches to when influxdb can't be reached, empty disables spooling")
+	flag.Int64Var(&config.SpoolMaxBytes, "spool-max-bytes", 10*1024*1024, "max total size of spooled batches, oldest batches are dropped first once exceeded")
+	flag.StringVar(&config.Output, "output", "influxdb", "the metrics backend to write to, one of 'influxdb', 'prometheus-rw', 'otlp-http'")
+	flag.StringVar(&config.RemoteWriteURL, "remote-write-url", "", "the prometheus remote-write endpoint to write to, required when --output is 'prometheus-rw'")
+	flag.StringVar(&config.OTLPEndpoint, "otlp-endpoint", "", "the base url of the otlp/http collector to write to, required when --output is 'otlp-http'")
+	flag.StringVar(&config.NameTemplate, "name-template", "", "a text/template string rendering the measurement name(s) for a point, one per line; empty falls back to the legacy/non-legacy name splitting")
+	flag.StringVar(&config.TagsTemplate, "tags-template", "", "a text/template string rendering a comma-separated tag=value list for a point; empty falls back to the legacy/non-legacy sensu_entity_name tagging")
+}
+
+func main() {
+	flag.Parse()
+
+	eventJSON, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to read stdin: %s\n", err)
+		os.Exit(1)
+	}
+
+	event := &corev2.Event{}
+	if err := json.Unmarshal(eventJSON, event); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to unmarshal event: %s\n", err)
+		os.Exit(1)
+	}
+
+	if err := checkArgs(event); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+
+	if err := sendMetrics(event); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+}
+
+func checkArgs(event *corev2.Event) error {
+	if _, ok := precisionMap[config.Precision]; !ok {
+		return fmt.Errorf("--precision must be one of: ns, us, ms, s")
+	}
+	if len(config.Addr) == 0 {
+		return errors.New("--addr must be provided")
+	}
+	switch config.Output {
+	case "", "influxdb":
+		if config.APIVersion == "2" {
+			if len(config.Token) == 0 {
+				return errors.New("--token must be provided when --api-version is 2")
+			}
+			if len(config.Bucket) == 0 {
+				return errors.New("--bucket must be provided when --api-version is 2")
+			}
+		} else if len(config.DbName) == 0 {
+			return errors.New("--db-name must be provided")
+		}
+	case "prometheus-rw":
+		if len(config.RemoteWriteURL) == 0 {
+			return errors.New("--remote-write-url must be provided when --output is 'prometheus-rw'")
+		}
+	case "otlp-http":
+		if len(config.OTLPEndpoint) == 0 {
+			return errors.New("--otlp-endpoint must be provided when --output is 'otlp-http'")
+		}
+	default:
+		return errors.New("--output must be one of: influxdb, prometheus-rw, otlp-http")
+	}
+	if err := validateTemplate("name-template", config.NameTemplate); err != nil {
+		return err
+	}
+	if err := validateTemplate("tags-template", config.TagsTemplate); err != nil {
+		return err
+	}
+	if !event.HasMetrics() && !config.CheckStatusMetric {
+		return fmt.Errorf("event does not contain metrics")
+	}
+	return nil
+}
+
+func sendMetrics(event *corev2.Event) error {
+	var points []Point
+	var annotations []Annotation
+
+	// Add the check status field as a metric if requested. Measurement recorded as the check name.
+	if config.CheckStatusMetric && event.HasCheck() {
+		var statusMetric = &corev2.MetricPoint{
+			Name:      event.Check.Name + ".status",
+			Value:     float64(event.Check.Status),
+			Timestamp: event.Timestamp,
+		}
+		// bootstrap the event for metrics
+		if !event.HasMetrics() {
+			event.Metrics = new(corev2.Metrics)
+			event.Metrics.Points = make([]*corev2.MetricPoint, 0)
+		}
+		event.Metrics.Points = append(event.Metrics.Points, statusMetric)
+	}
+
+	if event.HasMetrics() {
+		labels := eventLabels(event)
+
+		for _, point := range event.Metrics.Points {
+			pointName := point.Name
+			if len(config.NameTemplate) == 0 && config.StripHost && strings.HasPrefix(pointName, event.Entity.Name) {
+				// Adding a char since we also want to strip the dot
+				pointName = pointName[len(event.Entity.Name)+1:]
+			}
+
+			tmplCtx := templateContext{
+				Entity:      event.Entity.Name,
+				PointName:   pointName,
+				PointTags:   metricTagsToMap(point.Tags),
+				EventLabels: labels,
+			}
+			if event.HasCheck() {
+				tmplCtx.Check = event.Check.Name
+			}
+
+			names, err := measurementNames(pointName, tmplCtx)
+			if err != nil {
+				return err
+			}
+			if len(names) == 0 {
+				continue
+			}
+
+			fields := setFields(pointName, point.Value)
+
+			tags, err := pointTags(event.Entity.Name, point.Tags, tmplCtx)
+			if err != nil {
+				return err
+			}
+
+			timestamp, err := setTime(point.Timestamp)
+			if err != nil {
+				return err
+			}
+
+			for _, name := range names {
+				points = append(points, Point{Name: name, Tags: tags, Fields: fields, Timestamp: timestamp})
+			}
+		}
+	}
+
+	// 1.x handler parity
+	if eventNeedsAnnotation(event) {
+		tags := map[string]string{
+			"entity": event.Entity.Name,
+			"check":  event.Check.Name,
+		}
+		fields := map[string]interface{}{
+			"title":       fmt.Sprintf("%q", "Sensu Event"),
+			"description": fmt.Sprintf("%q", formattedMessage(event)),
+			"status":      int(event.Check.Status),
+			"occurrences": int(event.Check.Occurrences),
+		}
+
+		timestamp, err := setTime(event.Timestamp)
+		if err != nil {
+			return err
+		}
+
+		annotations = append(annotations, Annotation{Name: "sensu_event", Tags: tags, Fields: fields, Timestamp: timestamp})
+	}
+
+	if len(points) == 0 && len(annotations) == 0 {
+		return nil
+	}
+
+	exporter, err := newExporter()
+	if err != nil {
+		return err
+	}
+
+	return exporter.Export(context.Background(), event.Entity.Name, points, annotations)
+}
+
+// Determine if an event needs an annotation
+func eventNeedsAnnotation(event *corev2.Event) bool {
+	// No check, no need to be here
+	if !event.HasCheck() {
+		return false
+	}
+
+	// Alert (should this only happen on occurrence == 1?)
+	if event.Check.Status != 0 {
+		return true
+	}
+
+	// Status 0, steady as she goes, not an alert
+	if event.Check.Occurrences > 1 {
+		return false
+	}
+
+	// Status 0, but first occurrence so it's a resolution, assumed
+	return true
+}
+
+// formattedMessage builds a short human-readable summary of an event,
+// e.g. "ALERT - entity1/check1 : FAILURE".
+func formattedMessage(event *corev2.Event) string {
+	action := "ALERT"
+	if event.Check.Status == 0 {
+		action = "RESOLVE"
+	}
+	return fmt.Sprintf("%s - %s/%s : %s", action, event.Entity.Name, event.Check.Name, event.Check.Output)
+}
+
+// set measurement name
+func setName(name string) string {
+	// Legacy always returns full name
+	if config.Legacy {
+		return name
+	}
+
+	// if name includes '.' then only the first one is used
+	return strings.Split(name, ".")[0]
+}
+
+// set field key/value
+func setFields(name string, value interface{}) map[string]interface{} {
+	fields := make(map[string]interface{})
+	// Legacy always uses value as the key
+	if config.Legacy {
+		fields["value"] = value
+		return fields
+	}
+
+	nameField := strings.Split(name, ".")
+	// names with '.', use first part as measurement name and rest as key for the value
+	if len(nameField) > 1 {
+		fields[strings.Join(nameField[1:], ".")] = value
+		return fields
+	}
+
+	fields["value"] = value
+	return fields
+}
+
+func setTags(name string, tags []*corev2.MetricTag) map[string]string {
+	ntags := make(map[string]string)
+
+	if config.Legacy {
+		ntags["host"] = name
+	} else {
+		ntags["sensu_entity_name"] = name
+	}
+
+	for _, tag := range tags {
+		ntags[tag.Name] = tag.Value
+	}
+
+	return ntags
+}
+
+func setTime(timestamp int64) (time.Time, error) {
+	stringTimestamp := strconv.FormatInt(timestamp, 10)
+	if len(stringTimestamp) > 10 {
+		stringTimestamp = stringTimestamp[:10]
+	}
+	t, err := strconv.ParseInt(stringTimestamp, 10, 64)
+	if err != nil {
+		return time.Now(), err
+	}
+
+	return time.Unix(t, 0), nil
+}
+
+// timestampForPrecision converts t to the integer epoch value matching
+// config.Precision, so the magnitude of the line-protocol timestamp agrees
+// with the precision= query parameter sent in writeURL.
+func timestampForPrecision(t time.Time, precision string) int64 {
+	switch precision {
+	case "us":
+		return t.UnixMicro()
+	case "ms":
+		return t.UnixMilli()
+	case "s":
+		return t.Unix()
+	default:
+		return t.UnixNano()
+	}
+}
+
+// formatLine renders a single line-protocol line: measurement, sorted tags,
+// sorted fields and a timestamp scaled to config.Precision. Tags with an
+// empty value are omitted, matching InfluxDB's own handling of blank tag
+// values.
+func formatLine(name string, tags map[string]string, fields map[string]interface{}, timestamp time.Time) string {
+	var sb strings.Builder
+	sb.WriteString(name)
+
+	tagKeys := make([]string, 0, len(tags))
+	for k := range tags {
+		tagKeys = append(tagKeys, k)
+	}
+	sort.Strings(tagKeys)
+	for _, k := range tagKeys {
+		v := tags[k]
+		if len(v) == 0 {
+			continue
+		}
+		sb.WriteString(",")
+		sb.WriteString(k)
+		sb.WriteString("=")
+		sb.WriteString(v)
+	}
+
+	fieldKeys := make([]string, 0, len(fields))
+	for k := range fields {
+		fieldKeys = append(fieldKeys, k)
+	}
+	sort.Strings(fieldKeys)
+	fieldParts := make([]string, 0, len(fieldKeys))
+	for _, k := range fieldKeys {
+		fieldParts = append(fieldParts, k+"="+formatFieldValue(fields[k]))
+	}
+
+	sb.WriteString(" ")
+	sb.WriteString(strings.Join(fieldParts, ","))
+	sb.WriteString(" ")
+	sb.WriteString(strconv.FormatInt(timestampForPrecision(timestamp, config.Precision), 10))
+
+	return sb.String()
+}
+
+func formatFieldValue(value interface{}) string {
+	switch v := value.(type) {
+	case int:
+		return strconv.FormatInt(int64(v), 10) + "i"
+	case int64:
+		return strconv.FormatInt(v, 10) + "i"
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		return `"` + strings.ReplaceAll(v, `"`, `\"`) + `"`
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
@@ -6,7 +6,10 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	corev2 "github.com/sensu/sensu-go/api/core/v2"
 	"github.com/stretchr/testify/assert"
@@ -290,3 +293,164 @@ func TestMain(t *testing.T) {
 	main()
 	assert.True(requestReceived)
 }
+
+func TestSendMetricsV1Unchanged(t *testing.T) {
+	assert := assert.New(t)
+	event := corev2.FixtureEvent("entity1", "check1")
+	event.Check = nil
+	event.Metrics = corev2.FixtureMetrics()
+
+	var apiStub = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal("/write", r.URL.Path)
+		assert.Equal("mydb", r.URL.Query().Get("db"))
+		assert.Equal("s", r.URL.Query().Get("precision"))
+		username, password, ok := r.BasicAuth()
+		assert.True(ok)
+		assert.Equal("bar", username)
+		assert.Equal("baz", password)
+		assert.False(strings.HasPrefix(r.Header.Get("Authorization"), "Token")) // sanity: basic auth, not a token header
+
+		body, _ := ioutil.ReadAll(r.Body)
+		assert.Contains(string(body), `answer,foo=bar,sensu_entity_name=entity1 value=42`)
+		assertLineTimestampPrecision(t, string(body), "s")
+		w.WriteHeader(http.StatusOK)
+		_, err := w.Write([]byte(`{"ok": true}`))
+		require.NoError(t, err)
+	}))
+
+	config.Addr = apiStub.URL
+	config.APIVersion = "1"
+	config.DbName = "mydb"
+	config.Username = "bar"
+	config.Password = "baz"
+	config.Precision = "s"
+	err := sendMetrics(event)
+	assert.NoError(err)
+}
+
+func TestSendMetricsV2(t *testing.T) {
+	assert := assert.New(t)
+	event := corev2.FixtureEvent("entity1", "check1")
+	event.Check = nil
+	event.Metrics = corev2.FixtureMetrics()
+
+	var apiStub = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal("/api/v2/write", r.URL.Path)
+		assert.Equal("my-org", r.URL.Query().Get("org"))
+		assert.Equal("my-bucket", r.URL.Query().Get("bucket"))
+		assert.Equal("ms", r.URL.Query().Get("precision"))
+		assert.Equal("Token my-token", r.Header.Get("Authorization"))
+
+		body, _ := ioutil.ReadAll(r.Body)
+		assert.Contains(string(body), `answer,foo=bar,sensu_entity_name=entity1 value=42`)
+		assertLineTimestampPrecision(t, string(body), "ms")
+		w.WriteHeader(http.StatusOK)
+		_, err := w.Write([]byte(`{"ok": true}`))
+		require.NoError(t, err)
+	}))
+
+	config.Addr = apiStub.URL
+	config.APIVersion = "2"
+	config.Token = "my-token"
+	config.Org = "my-org"
+	config.Bucket = "my-bucket"
+	config.Precision = "ms"
+	err := sendMetrics(event)
+	assert.NoError(err)
+
+	// reset for any tests that run after this one
+	config.APIVersion = "1"
+}
+
+// assertLineTimestampPrecision parses the trailing timestamp off a single
+// line-protocol line and checks its magnitude matches precision, so the
+// value written to the body agrees with the precision= query parameter sent
+// in the same request.
+func assertLineTimestampPrecision(t *testing.T, line, precision string) {
+	t.Helper()
+	fields := strings.Fields(strings.TrimSpace(line))
+	require.NotEmpty(t, fields)
+	timestamp, err := strconv.ParseInt(fields[len(fields)-1], 10, 64)
+	require.NoError(t, err)
+
+	now := time.Now()
+	var want int64
+	switch precision {
+	case "s":
+		want = now.Unix()
+	case "ms":
+		want = now.UnixMilli()
+	case "us":
+		want = now.UnixMicro()
+	default:
+		want = now.UnixNano()
+	}
+
+	// Compare order of magnitude (digit count) rather than the exact value,
+	// since "now" in the test and "now" when the point was recorded differ
+	// by a few milliseconds.
+	assert.Equal(t, len(strconv.FormatInt(want, 10)), len(strconv.FormatInt(timestamp, 10)))
+}
+
+func TestCheckArgsAPIVersion2(t *testing.T) {
+	assert := assert.New(t)
+	event := corev2.FixtureEvent("entity1", "check1")
+	event.Metrics = corev2.FixtureMetrics()
+
+	config.Addr = "http://localhost:8086"
+	config.Precision = "s"
+	config.APIVersion = "2"
+	config.Token = ""
+	config.Bucket = ""
+	err := checkArgs(event)
+	assert.Error(err)
+
+	config.Token = "my-token"
+	config.Bucket = "my-bucket"
+	err = checkArgs(event)
+	assert.NoError(err)
+
+	config.APIVersion = "1"
+}
+
+func TestCheckArgsPrometheusRemoteWrite(t *testing.T) {
+	assert := assert.New(t)
+	event := corev2.FixtureEvent("entity1", "check1")
+	event.Metrics = corev2.FixtureMetrics()
+
+	config.Addr = "http://localhost:8086"
+	config.Precision = "s"
+	config.Output = "prometheus-rw"
+	config.DbName = ""
+	config.RemoteWriteURL = ""
+	err := checkArgs(event)
+	assert.Error(err)
+
+	config.RemoteWriteURL = "http://localhost:9090/api/v1/write"
+	err = checkArgs(event)
+	assert.NoError(err)
+
+	config.Output = ""
+	config.RemoteWriteURL = ""
+}
+
+func TestCheckArgsOTLPHTTP(t *testing.T) {
+	assert := assert.New(t)
+	event := corev2.FixtureEvent("entity1", "check1")
+	event.Metrics = corev2.FixtureMetrics()
+
+	config.Addr = "http://localhost:8086"
+	config.Precision = "s"
+	config.Output = "otlp-http"
+	config.DbName = ""
+	config.OTLPEndpoint = ""
+	err := checkArgs(event)
+	assert.Error(err)
+
+	config.OTLPEndpoint = "http://localhost:4318"
+	err = checkArgs(event)
+	assert.NoError(err)
+
+	config.Output = ""
+	config.OTLPEndpoint = ""
+}
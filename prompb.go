@@ -0,0 +1,328 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// This file implements just enough of the Prometheus remote-write wire
+// format (https://prometheus.io/docs/concepts/remote_write_spec/) to build
+// and parse a WriteRequest. Pulling in github.com/prometheus/prometheus for
+// prompb.WriteRequest drags in its whole dependency tree (etcd, grpc,
+// genproto, ...) for four tiny, stable messages, so we hand-roll the
+// protobuf encoding instead.
+
+// Label is a single name/value pair attached to a TimeSeries.
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Sample is a single value/timestamp pair within a TimeSeries.
+type Sample struct {
+	Value     float64
+	Timestamp int64 // milliseconds since the Unix epoch
+}
+
+// TimeSeries is a set of labeled samples, matching prompb.TimeSeries.
+type TimeSeries struct {
+	Labels  []Label
+	Samples []Sample
+}
+
+// WriteRequest is the top-level remote-write payload, matching
+// prompb.WriteRequest.
+type WriteRequest struct {
+	Timeseries []TimeSeries
+}
+
+const (
+	wireVarint   = 0
+	wireFixed64  = 1
+	wireLenDelim = 2
+)
+
+func appendTag(b []byte, fieldNum, wireType int) []byte {
+	return appendVarint(b, uint64(fieldNum)<<3|uint64(wireType))
+}
+
+func appendVarint(b []byte, v uint64) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+func appendVarintField(b []byte, fieldNum int, v uint64) []byte {
+	b = appendTag(b, fieldNum, wireVarint)
+	return appendVarint(b, v)
+}
+
+func appendFixed64Field(b []byte, fieldNum int, v uint64) []byte {
+	b = appendTag(b, fieldNum, wireFixed64)
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func appendLenDelimField(b []byte, fieldNum int, data []byte) []byte {
+	b = appendTag(b, fieldNum, wireLenDelim)
+	b = appendVarint(b, uint64(len(data)))
+	return append(b, data...)
+}
+
+// Marshal encodes l as a protobuf Label message.
+func (l Label) Marshal() ([]byte, error) {
+	var b []byte
+	if len(l.Name) > 0 {
+		b = appendLenDelimField(b, 1, []byte(l.Name))
+	}
+	if len(l.Value) > 0 {
+		b = appendLenDelimField(b, 2, []byte(l.Value))
+	}
+	return b, nil
+}
+
+// Marshal encodes s as a protobuf Sample message.
+func (s Sample) Marshal() ([]byte, error) {
+	b := appendFixed64Field(nil, 1, math.Float64bits(s.Value))
+	b = appendVarintField(b, 2, uint64(s.Timestamp))
+	return b, nil
+}
+
+// Marshal encodes t as a protobuf TimeSeries message.
+func (t TimeSeries) Marshal() ([]byte, error) {
+	var b []byte
+	for _, l := range t.Labels {
+		lb, err := l.Marshal()
+		if err != nil {
+			return nil, err
+		}
+		b = appendLenDelimField(b, 1, lb)
+	}
+	for _, s := range t.Samples {
+		sb, err := s.Marshal()
+		if err != nil {
+			return nil, err
+		}
+		b = appendLenDelimField(b, 2, sb)
+	}
+	return b, nil
+}
+
+// Marshal encodes w as a protobuf WriteRequest message.
+func (w *WriteRequest) Marshal() ([]byte, error) {
+	var b []byte
+	for _, ts := range w.Timeseries {
+		tsb, err := ts.Marshal()
+		if err != nil {
+			return nil, err
+		}
+		b = appendLenDelimField(b, 1, tsb)
+	}
+	return b, nil
+}
+
+// readTag reads a protobuf field tag from the front of b, returning the
+// field number, wire type, and number of bytes consumed.
+func readTag(b []byte) (fieldNum, wireType, n int, err error) {
+	v, n, err := readVarint(b)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	return int(v >> 3), int(v & 0x7), n, nil
+}
+
+func readVarint(b []byte) (uint64, int, error) {
+	var v uint64
+	var shift uint
+	for i, c := range b {
+		if i > 9 {
+			return 0, 0, fmt.Errorf("prompb: varint too long")
+		}
+		v |= uint64(c&0x7f) << shift
+		if c < 0x80 {
+			return v, i + 1, nil
+		}
+		shift += 7
+	}
+	return 0, 0, fmt.Errorf("prompb: truncated varint")
+}
+
+// readLenDelim reads a length-delimited field's payload from the front of b,
+// returning the payload and the number of bytes consumed (length prefix and
+// payload combined).
+func readLenDelim(b []byte) ([]byte, int, error) {
+	length, n, err := readVarint(b)
+	if err != nil {
+		return nil, 0, err
+	}
+	end := n + int(length)
+	if end > len(b) {
+		return nil, 0, fmt.Errorf("prompb: truncated message")
+	}
+	return b[n:end], end, nil
+}
+
+// skipField consumes a field's value given its wire type, returning the
+// number of bytes consumed.
+func skipField(b []byte, wireType int) (int, error) {
+	switch wireType {
+	case wireVarint:
+		_, n, err := readVarint(b)
+		return n, err
+	case wireFixed64:
+		if len(b) < 8 {
+			return 0, fmt.Errorf("prompb: truncated fixed64")
+		}
+		return 8, nil
+	case wireLenDelim:
+		_, n, err := readLenDelim(b)
+		return n, err
+	default:
+		return 0, fmt.Errorf("prompb: unsupported wire type %d", wireType)
+	}
+}
+
+// Unmarshal decodes a protobuf Label message from b.
+func (l *Label) Unmarshal(b []byte) error {
+	for len(b) > 0 {
+		fieldNum, wireType, n, err := readTag(b)
+		if err != nil {
+			return err
+		}
+		b = b[n:]
+		switch fieldNum {
+		case 1:
+			payload, n, err := readLenDelim(b)
+			if err != nil {
+				return err
+			}
+			l.Name = string(payload)
+			b = b[n:]
+		case 2:
+			payload, n, err := readLenDelim(b)
+			if err != nil {
+				return err
+			}
+			l.Value = string(payload)
+			b = b[n:]
+		default:
+			n, err := skipField(b, wireType)
+			if err != nil {
+				return err
+			}
+			b = b[n:]
+		}
+	}
+	return nil
+}
+
+// Unmarshal decodes a protobuf Sample message from b.
+func (s *Sample) Unmarshal(b []byte) error {
+	for len(b) > 0 {
+		fieldNum, wireType, n, err := readTag(b)
+		if err != nil {
+			return err
+		}
+		b = b[n:]
+		switch fieldNum {
+		case 1:
+			if len(b) < 8 {
+				return fmt.Errorf("prompb: truncated fixed64")
+			}
+			s.Value = math.Float64frombits(binary.LittleEndian.Uint64(b[:8]))
+			b = b[8:]
+		case 2:
+			v, n, err := readVarint(b)
+			if err != nil {
+				return err
+			}
+			s.Timestamp = int64(v)
+			b = b[n:]
+		default:
+			n, err := skipField(b, wireType)
+			if err != nil {
+				return err
+			}
+			b = b[n:]
+		}
+	}
+	return nil
+}
+
+// Unmarshal decodes a protobuf TimeSeries message from b.
+func (t *TimeSeries) Unmarshal(b []byte) error {
+	for len(b) > 0 {
+		fieldNum, wireType, n, err := readTag(b)
+		if err != nil {
+			return err
+		}
+		b = b[n:]
+		switch fieldNum {
+		case 1:
+			payload, n, err := readLenDelim(b)
+			if err != nil {
+				return err
+			}
+			var l Label
+			if err := l.Unmarshal(payload); err != nil {
+				return err
+			}
+			t.Labels = append(t.Labels, l)
+			b = b[n:]
+		case 2:
+			payload, n, err := readLenDelim(b)
+			if err != nil {
+				return err
+			}
+			var s Sample
+			if err := s.Unmarshal(payload); err != nil {
+				return err
+			}
+			t.Samples = append(t.Samples, s)
+			b = b[n:]
+		default:
+			n, err := skipField(b, wireType)
+			if err != nil {
+				return err
+			}
+			b = b[n:]
+		}
+	}
+	return nil
+}
+
+// Unmarshal decodes a protobuf WriteRequest message from b.
+func (w *WriteRequest) Unmarshal(b []byte) error {
+	w.Timeseries = nil
+	for len(b) > 0 {
+		fieldNum, wireType, n, err := readTag(b)
+		if err != nil {
+			return err
+		}
+		b = b[n:]
+		switch fieldNum {
+		case 1:
+			payload, n, err := readLenDelim(b)
+			if err != nil {
+				return err
+			}
+			var ts TimeSeries
+			if err := ts.Unmarshal(payload); err != nil {
+				return err
+			}
+			w.Timeseries = append(w.Timeseries, ts)
+			b = b[n:]
+		default:
+			n, err := skipField(b, wireType)
+			if err != nil {
+				return err
+			}
+			b = b[n:]
+		}
+	}
+	return nil
+}
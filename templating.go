@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	corev2 "github.com/sensu/sensu-go/api/core/v2"
+)
+
+// templateContext is the data made available to --name-template and
+// --tags-template when rendering a point.
+type templateContext struct {
+	Entity      string
+	Check       string
+	PointName   string
+	PointTags   map[string]string
+	EventLabels map[string]string
+}
+
+// templateFuncs are the built-in functions available to --name-template and
+// --tags-template, in addition to the text/template defaults.
+var templateFuncs = template.FuncMap{
+	"trimSuffix": func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+	"split":      func(sep, s string) []string { return strings.Split(s, sep) },
+	"replace":    func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+	"sanitize":   sanitizeMetricName,
+}
+
+// renderTemplate parses and executes a --name-template/--tags-template
+// string against ctx. flagName is used only to make parse/execute errors
+// identify which flag they came from.
+func renderTemplate(flagName, text string, ctx templateContext) (string, error) {
+	tmpl, err := template.New(flagName).Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid --%s: %s", flagName, err)
+	}
+
+	var rendered strings.Builder
+	if err := tmpl.Execute(&rendered, ctx); err != nil {
+		return "", fmt.Errorf("failed to render --%s: %s", flagName, err)
+	}
+
+	return rendered.String(), nil
+}
+
+// validateTemplate parses text (if non-empty) just to surface a syntax error
+// up front, rather than the first time a point is rendered.
+func validateTemplate(flagName, text string) error {
+	if len(text) == 0 {
+		return nil
+	}
+	_, err := template.New(flagName).Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return fmt.Errorf("invalid --%s: %s", flagName, err)
+	}
+	return nil
+}
+
+// measurementNames returns the measurement name(s) a point should be
+// recorded under. With --name-template unset, this is the legacy/non-legacy
+// behavior of setName. With --name-template set, the template is rendered
+// and split on newlines, so a template can fan a single point out into
+// multiple measurements, e.g. by ranging over .PointTags.
+func measurementNames(pointName string, ctx templateContext) ([]string, error) {
+	if len(config.NameTemplate) == 0 {
+		name := setName(pointName)
+		if len(name) == 0 {
+			return nil, nil
+		}
+		return []string{name}, nil
+	}
+
+	rendered, err := renderTemplate("name-template", config.NameTemplate, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, line := range strings.Split(rendered, "\n") {
+		if line = strings.TrimSpace(line); len(line) > 0 {
+			names = append(names, line)
+		}
+	}
+	return names, nil
+}
+
+// pointTags returns the tag set a point should be recorded with. With
+// --tags-template unset, this is the legacy/non-legacy sensu_entity_name/host
+// tagging of setTags. With --tags-template set, the template is rendered and
+// parsed as a comma-separated list of tag=value pairs.
+func pointTags(entityName string, rawTags []*corev2.MetricTag, ctx templateContext) (map[string]string, error) {
+	if len(config.TagsTemplate) == 0 {
+		return setTags(entityName, rawTags), nil
+	}
+
+	rendered, err := renderTemplate("tags-template", config.TagsTemplate, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseTagPairs(rendered), nil
+}
+
+// parseTagPairs parses a rendered tags-template into a tag map. Pairs may be
+// separated by commas and/or newlines, e.g. to let a template build the list
+// with a range over .PointTags.
+func parseTagPairs(rendered string) map[string]string {
+	tags := make(map[string]string)
+	for _, field := range strings.FieldsFunc(rendered, func(r rune) bool { return r == ',' || r == '\n' }) {
+		name, value, ok := strings.Cut(strings.TrimSpace(field), "=")
+		if !ok || len(name) == 0 {
+			continue
+		}
+		tags[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+	return tags
+}
+
+// metricTagsToMap converts a metric point's tags to the map form expected by
+// templateContext.PointTags.
+func metricTagsToMap(tags []*corev2.MetricTag) map[string]string {
+	m := make(map[string]string, len(tags))
+	for _, tag := range tags {
+		m[tag.Name] = tag.Value
+	}
+	return m
+}
+
+// eventLabels merges an event's entity and check labels into a single map
+// for use as templateContext.EventLabels, with check labels taking
+// precedence since they're the more specific of the two.
+func eventLabels(event *corev2.Event) map[string]string {
+	labels := make(map[string]string)
+	if event.Entity != nil {
+		for k, v := range event.Entity.Labels {
+			labels[k] = v
+		}
+	}
+	if event.HasCheck() {
+		for k, v := range event.Check.Labels {
+			labels[k] = v
+		}
+	}
+	return labels
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	corev2 "github.com/sensu/sensu-go/api/core/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSendMetricsTagsTemplateFromEventLabel(t *testing.T) {
+	assert := assert.New(t)
+	event := corev2.FixtureEvent("entity1", "check1")
+	event.Check = nil
+	event.Metrics = corev2.FixtureMetrics()
+	event.Entity.Labels = map[string]string{"os.hostname": "host1"}
+
+	var body string
+	apiStub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		body = string(b)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer apiStub.Close()
+
+	config.Addr = apiStub.URL
+	config.TagsTemplate = `host={{index .EventLabels "os.hostname"}}`
+	defer func() { config.TagsTemplate = "" }()
+
+	require.NoError(t, sendMetrics(event))
+	assert.Contains(body, "answer,host=host1 value=42")
+}
+
+func TestSendMetricsNameTemplateStripsDomainSuffix(t *testing.T) {
+	assert := assert.New(t)
+	event := corev2.FixtureEvent("prod-eu-db01.company.com", "check1")
+	event.Check = nil
+	event.Metrics = corev2.FixtureMetrics()
+	event.Metrics.Points[0].Name = "answer.company.com"
+
+	var body string
+	apiStub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		body = string(b)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer apiStub.Close()
+
+	config.Addr = apiStub.URL
+	config.NameTemplate = `{{trimSuffix ".company.com" .PointName}}`
+	defer func() { config.NameTemplate = "" }()
+
+	require.NoError(t, sendMetrics(event))
+	assert.Contains(body, "answer,foo=bar,sensu_entity_name=prod-eu-db01.company.com company.com=42")
+}
+
+func TestSendMetricsNameTemplateFansOutToMultipleMeasurements(t *testing.T) {
+	assert := assert.New(t)
+	event := corev2.FixtureEvent("entity1", "check1")
+	event.Check = nil
+	event.Metrics = corev2.FixtureMetrics()
+	event.Metrics.Points[0].Tags = append(event.Metrics.Points[0].Tags, &corev2.MetricTag{Name: "region", Value: "eu"})
+
+	var body string
+	apiStub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		body = string(b)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer apiStub.Close()
+
+	config.Addr = apiStub.URL
+	config.NameTemplate = "{{range $k, $v := .PointTags}}{{$.PointName}}_{{$v}}\n{{end}}"
+	defer func() { config.NameTemplate = "" }()
+
+	require.NoError(t, sendMetrics(event))
+	assert.Contains(body, "answer_bar,foo=bar,region=eu,sensu_entity_name=entity1 value=42")
+	assert.Contains(body, "answer_eu,foo=bar,region=eu,sensu_entity_name=entity1 value=42")
+}
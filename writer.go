@@ -0,0 +1,307 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// defaultBatchBytes is the batch size threshold used when config.BatchBytes
+// is left unset (e.g. by callers that construct a Writer directly in tests).
+const defaultBatchBytes = 512 * 1024
+
+// defaultMaxRetries caps how many times a batch is retried against InfluxDB
+// before it's spooled to disk for later replay.
+const defaultMaxRetries = 5
+
+// httpStatusError wraps a non-2xx InfluxDB response so the retry logic can
+// tell a permanent rejection (4xx) apart from a transient one (5xx).
+type httpStatusError struct {
+	statusCode int
+	status     string
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("unexpected response from influxdb: %s", e.status)
+}
+
+func (e *httpStatusError) retryable() bool {
+	return e.statusCode >= 500
+}
+
+// postFunc performs the actual HTTP write of a (possibly gzip-encoded) body
+// and is supplied by the caller so the writer itself stays transport-agnostic.
+type postFunc func(ctx context.Context, body []byte, gzipped bool) error
+
+// Writer accumulates line-protocol points into batches, flushing them to
+// InfluxDB once a size or time threshold is reached. Batches that can't be
+// delivered after repeated retries are spooled to disk and replayed the next
+// time a batch is flushed successfully.
+type Writer struct {
+	batchBytes    int
+	batchFlush    time.Duration
+	compress      bool
+	spoolDir      string
+	spoolMaxBytes int64
+	post          postFunc
+
+	buf        bytes.Buffer
+	bufOpened  time.Time
+	spoolCount int
+}
+
+// NewWriter builds a Writer using the handler's configured batching,
+// compression and spool settings.
+func NewWriter(post postFunc) *Writer {
+	batchBytes := config.BatchBytes
+	if batchBytes <= 0 {
+		batchBytes = defaultBatchBytes
+	}
+	return &Writer{
+		batchBytes:    batchBytes,
+		batchFlush:    config.BatchFlush,
+		compress:      config.Compress,
+		spoolDir:      config.SpoolDir,
+		spoolMaxBytes: config.SpoolMaxBytes,
+		post:          post,
+	}
+}
+
+// WriteLine appends a single line-protocol line to the current batch,
+// flushing it first if adding the line would exceed the configured batch
+// size or the batch has been open longer than the configured flush window.
+func (w *Writer) WriteLine(ctx context.Context, line string) error {
+	if w.buf.Len() == 0 {
+		w.bufOpened = time.Now()
+	} else if w.buf.Len()+len(line)+1 > w.batchBytes || (w.batchFlush > 0 && time.Since(w.bufOpened) >= w.batchFlush) {
+		if err := w.Flush(ctx); err != nil {
+			return err
+		}
+		w.bufOpened = time.Now()
+	}
+
+	w.buf.WriteString(line)
+	w.buf.WriteString("\n")
+	return nil
+}
+
+// Flush sends the current batch (if any), then attempts to replay any
+// batches that were previously spooled to disk after a delivery failure.
+func (w *Writer) Flush(ctx context.Context) error {
+	if err := w.replaySpool(ctx); err != nil {
+		return err
+	}
+
+	if w.buf.Len() == 0 {
+		return nil
+	}
+
+	body := make([]byte, w.buf.Len())
+	copy(body, w.buf.Bytes())
+	w.buf.Reset()
+
+	return w.sendWithRetry(ctx, body)
+}
+
+// sendWithRetry delivers body, retrying 5xx/network failures with
+// exponential backoff and jitter. 4xx responses are dropped immediately, as
+// retrying them would never succeed. If every retry is exhausted, the batch
+// is spooled to disk instead of being lost.
+func (w *Writer) sendWithRetry(ctx context.Context, body []byte) error {
+	backoff := 100 * time.Millisecond
+
+	for attempt := 0; attempt <= defaultMaxRetries; attempt++ {
+		err := w.send(ctx, body)
+		if err == nil {
+			return nil
+		}
+
+		var statusErr *httpStatusError
+		if errors.As(err, &statusErr) && !statusErr.retryable() {
+			// A 4xx means InfluxDB will never accept this batch; drop it.
+			return nil
+		}
+
+		if attempt == defaultMaxRetries {
+			return w.spool(body)
+		}
+
+		jitter := time.Duration(rand.Int63n(int64(backoff)))
+		time.Sleep(backoff + jitter)
+		backoff *= 2
+	}
+
+	return nil
+}
+
+// send gzip-encodes body when configured to and hands it to the transport's
+// postFunc.
+func (w *Writer) send(ctx context.Context, body []byte) error {
+	if !w.compress {
+		return w.post(ctx, body, false)
+	}
+
+	var gzipped bytes.Buffer
+	gw := gzip.NewWriter(&gzipped)
+	if _, err := gw.Write(body); err != nil {
+		return err
+	}
+	if err := gw.Close(); err != nil {
+		return err
+	}
+
+	return w.post(ctx, gzipped.Bytes(), true)
+}
+
+// spool persists a batch that couldn't be delivered so it can be replayed
+// later. If the spool directory is at capacity, the oldest spooled batches
+// are dropped to make room for the newest one.
+func (w *Writer) spool(body []byte) error {
+	if len(w.spoolDir) == 0 {
+		return fmt.Errorf("influxdb write failed after %d retries and no spool-dir is configured, dropping batch", defaultMaxRetries)
+	}
+
+	if err := os.MkdirAll(w.spoolDir, 0o755); err != nil {
+		return err
+	}
+
+	if w.spoolMaxBytes > 0 {
+		if err := w.makeRoomForSpool(int64(len(body))); err != nil {
+			return err
+		}
+	}
+
+	w.spoolCount++
+	name := fmt.Sprintf("%020d-%d.spool", time.Now().UnixNano(), w.spoolCount)
+	return os.WriteFile(filepath.Join(w.spoolDir, name), body, 0o644)
+}
+
+// makeRoomForSpool removes the oldest spooled batches until there's enough
+// room under spoolMaxBytes for a new batch of the given size.
+func (w *Writer) makeRoomForSpool(newSize int64) error {
+	entries, err := spoolFiles(w.spoolDir)
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	for _, e := range entries {
+		total += e.size
+	}
+
+	for total+newSize > w.spoolMaxBytes && len(entries) > 0 {
+		oldest := entries[0]
+		if err := os.Remove(filepath.Join(w.spoolDir, oldest.name)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		total -= oldest.size
+		entries = entries[1:]
+	}
+
+	return nil
+}
+
+// replaySpool attempts, in order from oldest to newest, to deliver any
+// spooled batches left over from earlier failures. It stops at the first
+// batch that still fails to send, leaving the rest spooled for next time.
+func (w *Writer) replaySpool(ctx context.Context) error {
+	if len(w.spoolDir) == 0 {
+		return nil
+	}
+
+	entries, err := spoolFiles(w.spoolDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	for _, e := range entries {
+		path := filepath.Join(w.spoolDir, e.name)
+		body, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		if err := w.send(ctx, body); err != nil {
+			// Leave this and later batches spooled for the next attempt.
+			return nil
+		}
+
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type spoolFile struct {
+	name string
+	size int64
+}
+
+// spoolFiles lists *.spool files in dir, oldest first (filenames are
+// nanosecond-timestamp prefixed, so a lexical sort is a chronological sort).
+func spoolFiles(dir string) ([]spoolFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []spoolFile
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".spool") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, spoolFile{name: entry.Name(), size: info.Size()})
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].name < files[j].name })
+	return files, nil
+}
+
+// httpPost POSTs body to url, setting Content-Encoding: gzip when gzipped is
+// true and applying the given header-setting function (used to attach
+// authentication headers appropriate to the configured API version).
+func httpPost(url string, setHeaders func(*http.Request)) postFunc {
+	return func(ctx context.Context, body []byte, gzipped bool) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+
+		setHeaders(req)
+		if gzipped {
+			req.Header.Set("Content-Encoding", "gzip")
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		_, _ = io.Copy(io.Discard, resp.Body)
+
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return &httpStatusError{statusCode: resp.StatusCode, status: resp.Status}
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriterRetriesUntilSuccess(t *testing.T) {
+	assert := assert.New(t)
+
+	var mu sync.Mutex
+	failuresLeft := 2
+	deliveries := 0
+	var bodies []string
+
+	apiStub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		body, _ := ioutil.ReadAll(r.Body)
+		if failuresLeft > 0 {
+			failuresLeft--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		deliveries++
+		bodies = append(bodies, string(body))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer apiStub.Close()
+
+	writer := NewWriter(httpPost(apiStub.URL, func(*http.Request) {}))
+	require.NoError(t, writer.WriteLine(context.Background(), "answer,foo=bar value=42 1"))
+	require.NoError(t, writer.Flush(context.Background()))
+
+	assert.Equal(1, deliveries)
+	require.Len(t, bodies, 1)
+	assert.Contains(bodies[0], "answer,foo=bar value=42 1")
+}
+
+func TestWriterDropsClientErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	requests := 0
+	apiStub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer apiStub.Close()
+
+	writer := NewWriter(httpPost(apiStub.URL, func(*http.Request) {}))
+	require.NoError(t, writer.WriteLine(context.Background(), "answer,foo=bar value=42 1"))
+	assert.NoError(writer.Flush(context.Background()), "a 4xx response should be dropped, not treated as an error")
+	assert.Equal(1, requests, "a 4xx response must not be retried")
+}
+
+func TestWriterSpoolsAfterExhaustingRetries(t *testing.T) {
+	assert := assert.New(t)
+
+	apiStub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer apiStub.Close()
+
+	spoolDir := t.TempDir()
+	writer := NewWriter(httpPost(apiStub.URL, func(*http.Request) {}))
+	writer.spoolDir = spoolDir
+	writer.spoolMaxBytes = 1024 * 1024
+
+	require.NoError(t, writer.WriteLine(context.Background(), "answer,foo=bar value=42 1"))
+	assert.NoError(writer.Flush(context.Background()))
+
+	files, err := os.ReadDir(spoolDir)
+	require.NoError(t, err)
+	assert.Len(files, 1)
+}
+
+func TestWriterSpoolDropsOldestWhenFull(t *testing.T) {
+	assert := assert.New(t)
+
+	spoolDir := t.TempDir()
+	writer := NewWriter(httpPost("http://unused.invalid", func(*http.Request) {}))
+	writer.spoolDir = spoolDir
+	// Only room for two ~30 byte batches.
+	writer.spoolMaxBytes = 60
+
+	require.NoError(t, writer.spool([]byte("batch-one-xxxxxxxxxxxxxxxxxxxx")))
+	require.NoError(t, writer.spool([]byte("batch-two-xxxxxxxxxxxxxxxxxxxx")))
+	require.NoError(t, writer.spool([]byte("batch-three-xxxxxxxxxxxxxxxxxx")))
+
+	files, err := spoolFiles(spoolDir)
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+
+	var contents []string
+	for _, f := range files {
+		body, err := os.ReadFile(filepath.Join(spoolDir, f.name))
+		require.NoError(t, err)
+		contents = append(contents, string(body))
+	}
+	assert.NotContains(contents, "batch-one-xxxxxxxxxxxxxxxxxxxx")
+	assert.Contains(contents, "batch-three-xxxxxxxxxxxxxxxxxx")
+}
+
+func TestWriterReplaysSpoolOnNextFlush(t *testing.T) {
+	assert := assert.New(t)
+
+	var delivered []string
+	apiStub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		delivered = append(delivered, string(body))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer apiStub.Close()
+
+	spoolDir := t.TempDir()
+	writer := NewWriter(httpPost(apiStub.URL, func(*http.Request) {}))
+	writer.spoolDir = spoolDir
+	writer.spoolMaxBytes = 1024 * 1024
+
+	require.NoError(t, writer.spool([]byte("spooled,foo=bar value=1 1\n")))
+
+	require.NoError(t, writer.WriteLine(context.Background(), "fresh,foo=bar value=2 2"))
+	require.NoError(t, writer.Flush(context.Background()))
+
+	require.Len(t, delivered, 2)
+	assert.Contains(delivered[0], "spooled,foo=bar value=1 1")
+	assert.Contains(delivered[1], "fresh,foo=bar value=2 2")
+
+	files, err := os.ReadDir(spoolDir)
+	require.NoError(t, err)
+	assert.Len(files, 0)
+}
+
+func TestWriterCompressesWhenConfigured(t *testing.T) {
+	assert := assert.New(t)
+
+	var gotEncoding string
+	apiStub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotEncoding = r.Header.Get("Content-Encoding")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer apiStub.Close()
+
+	writer := NewWriter(httpPost(apiStub.URL, func(*http.Request) {}))
+	writer.compress = true
+
+	require.NoError(t, writer.WriteLine(context.Background(), "answer,foo=bar value=42 1"))
+	require.NoError(t, writer.Flush(context.Background()))
+
+	assert.Equal("gzip", gotEncoding)
+}